@@ -1,6 +1,10 @@
 package cluster
 
 import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,6 +27,112 @@ const (
 	StrategyRoundRobin Strategy = "roundrobin"
 )
 
+// strategyCooperativeSticky is the Strategy value partitionMap.Transition
+// treats as cooperative. It is deliberately unexported: nothing in this
+// package yet negotiates the COOPERATIVE protocol over JoinGroup/SyncGroup
+// or runs a leader-side sticky assignor, so there is no Consumer that can
+// actually select it. Transition, protocolFor, and the sticky UserData
+// codec below exist so that work has somewhere to plug in, but until a
+// Consumer calls Transition, exporting a Strategy a caller could pick with
+// zero runtime effect would be worse than not offering it.
+const strategyCooperativeSticky Strategy = "cooperative-sticky"
+
+// rebalanceProtocol is the rebalance behaviour a Strategy requires, as
+// reported by protocolFor. It is local to this member: nothing in this
+// package sends it in JoinGroup subscription metadata or reconciles it
+// with the protocols other group members support.
+type rebalanceProtocol string
+
+const (
+	// rebalanceProtocolEager revokes all partitions before every rebalance,
+	// as used by StrategyRange and StrategyRoundRobin.
+	rebalanceProtocolEager rebalanceProtocol = "eager"
+
+	// rebalanceProtocolCooperative allows members to retain partitions they
+	// already own across a rebalance, as used by strategyCooperativeSticky.
+	rebalanceProtocolCooperative rebalanceProtocol = "cooperative"
+)
+
+// protocolFor reports the rebalanceProtocol required by a Strategy. It
+// gates partitionMap.Transition's choice between stopping every partition
+// (rebalanceProtocolEager) and retaining the owned/assigned intersection
+// (rebalanceProtocolCooperative).
+func protocolFor(strategy Strategy) rebalanceProtocol {
+	if strategy == strategyCooperativeSticky {
+		return rebalanceProtocolCooperative
+	}
+	return rebalanceProtocolEager
+}
+
+// stickyUserDataVersion tags the wire format of stickyUserData so future
+// versions can be introduced without breaking members running an older one.
+const stickyUserDataVersion = 1
+
+// stickyUserData is what a member running strategyCooperativeSticky would
+// serialize into its JoinGroup subscription UserData, so the group
+// leader's assignor can compute a minimum-movement plan from every
+// member's current ownership instead of only its subscribed topics.
+type stickyUserData struct {
+	Owned []topicPartition
+}
+
+// encodeStickyUserData serializes owned as: a version byte, a varint
+// count, then for each partition a varint-length-prefixed topic name and a
+// varint partition number.
+func encodeStickyUserData(owned []topicPartition) []byte {
+	buf := []byte{stickyUserDataVersion}
+	buf = appendVarint(buf, int64(len(owned)))
+
+	for _, tp := range owned {
+		buf = appendVarint(buf, int64(len(tp.Topic)))
+		buf = append(buf, tp.Topic...)
+		buf = appendVarint(buf, int64(tp.Partition))
+	}
+
+	return buf
+}
+
+// decodeStickyUserData reverses encodeStickyUserData. Empty data decodes to
+// a member with no prior ownership, which is what a brand-new group member
+// sends on its first JoinGroup.
+func decodeStickyUserData(data []byte) (stickyUserData, error) {
+	if len(data) == 0 {
+		return stickyUserData{}, nil
+	}
+	if data[0] != stickyUserDataVersion {
+		return stickyUserData{}, fmt.Errorf("cluster: unsupported sticky UserData version %d", data[0])
+	}
+
+	buf := data[1:]
+	count, n := binary.Varint(buf)
+	if n <= 0 || count < 0 {
+		return stickyUserData{}, errors.New("cluster: truncated sticky UserData")
+	}
+	buf = buf[n:]
+
+	owned := make([]topicPartition, 0, count)
+	for ; count > 0; count-- {
+		topicLen, n := binary.Varint(buf)
+		if n <= 0 || topicLen < 0 || int64(len(buf)) < int64(n)+topicLen {
+			return stickyUserData{}, errors.New("cluster: truncated sticky UserData topic")
+		}
+		buf = buf[n:]
+
+		topic := string(buf[:topicLen])
+		buf = buf[topicLen:]
+
+		partition, n := binary.Varint(buf)
+		if n <= 0 {
+			return stickyUserData{}, errors.New("cluster: truncated sticky UserData partition")
+		}
+		buf = buf[n:]
+
+		owned = append(owned, topicPartition{Topic: topic, Partition: int32(partition)})
+	}
+
+	return stickyUserData{Owned: owned}, nil
+}
+
 // Error instances are wrappers for internal errors with a context and
 // may be returned through the consumer's Errors() channel
 type Error struct {
@@ -45,18 +155,111 @@ type offsetInfo struct {
 	Metadata       string
 }
 
-func (i offsetInfo) Serialize() offsetInfo {
-	meta := ""
-	for k, _ := range i.PendingOffsets {
-		meta += strconv.FormatInt(k, 10) + ","
+// OffsetInfo is the exported name for offsetInfo, used at the OffsetStore
+// interface boundary so that packages outside cluster can implement their
+// own offset-storage backend.
+type OffsetInfo = offsetInfo
+
+// defaultMaxMetadataBytes mirrors Kafka's default offset.metadata.max.bytes
+// broker setting, which silently truncates (and effectively drops) commits
+// whose metadata exceeds it.
+const defaultMaxMetadataBytes = 4096
+
+// metadataVersionV1 and metadataVersionV2 tag the encoding used for
+// offsetInfo.Metadata so that Deserialize can read metadata written by
+// older clients. v1 is the legacy comma-separated decimal list; v2 is the
+// delta/run-length/varint encoding produced by Serialize.
+const (
+	metadataVersionV1 = "v1:"
+	metadataVersionV2 = "v2:"
+)
+
+// ErrMetadataTooLarge is returned by Serialize when the encoded pending
+// offsets would exceed maxBytes.
+var ErrMetadataTooLarge = errors.New("cluster: pending offset metadata exceeds MaxMetadataBytes")
+
+// maxPendingOffsetRun caps a single run-length decoded by deserializeV2.
+// Serialize never produces a run this large--Kafka's own in-flight/lag
+// limits keep real gaps tiny--so a run above it can only come from
+// Metadata that was corrupted or tampered with after being written by a
+// FileOffsetStore/SQLOffsetStore, or a hand-crafted __consumer_offsets
+// record. Without this cap, a couple of bytes encoding a run near
+// math.MaxInt64 would make Deserialize spin and grow PendingOffsets
+// without bound.
+const maxPendingOffsetRun = 1 << 20
+
+// ErrPendingOffsetRunTooLarge is returned by Deserialize when Metadata
+// decodes to a run longer than maxPendingOffsetRun.
+var ErrPendingOffsetRunTooLarge = errors.New("cluster: pending offset run exceeds sanity limit")
+
+// Serialize encodes PendingOffsets into Metadata as a v2 payload: the
+// sorted offsets are delta-encoded against Offset, runs of consecutive
+// deltas of 1 are run-length-compressed, and the result is varint-encoded
+// and base64-ed. This keeps a contiguous gap of N pending offsets down to a
+// couple of varints instead of N decimal strings, so large in-flight sets
+// stay well under Kafka's commit metadata limit. If maxBytes is positive
+// and the encoded result would exceed it, Serialize returns
+// ErrMetadataTooLarge instead of producing a commit Kafka would truncate.
+func (i offsetInfo) Serialize(maxBytes int) (offsetInfo, error) {
+	if len(i.PendingOffsets) == 0 {
+		i.Metadata = ""
+		return i, nil
+	}
+
+	pending := make([]int64, 0, len(i.PendingOffsets))
+	for k := range i.PendingOffsets {
+		pending = append(pending, k)
 	}
+	sort.Slice(pending, func(a, b int) bool { return pending[a] < pending[b] })
+
+	var buf []byte
+	prev := i.Offset
+	for idx := 0; idx < len(pending); {
+		run := 1
+		for idx+run < len(pending) && pending[idx+run]-pending[idx+run-1] == 1 {
+			run++
+		}
+
+		buf = appendVarint(buf, pending[idx]-prev)
+		buf = appendVarint(buf, int64(run))
+
+		prev = pending[idx+run-1]
+		idx += run
+	}
+
+	encoded := metadataVersionV2 + base64.RawStdEncoding.EncodeToString(buf)
+	if maxBytes > 0 && len(encoded) > maxBytes {
+		return i, ErrMetadataTooLarge
+	}
+
+	i.Metadata = encoded
+	return i, nil
+}
 
-	i.Metadata = meta
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
 
-	return i
+// Deserialize decodes Metadata back into PendingOffsets, dispatching on its
+// version tag. Metadata with no recognized tag is treated as v1 for
+// back-compat with offsets committed before this format existed. It
+// returns an error if Metadata is malformed in a way that could otherwise
+// make decoding unbounded, e.g. ErrPendingOffsetRunTooLarge.
+func (i offsetInfo) Deserialize() (offsetInfo, error) {
+	switch {
+	case strings.HasPrefix(i.Metadata, metadataVersionV2):
+		return i.deserializeV2()
+	case strings.HasPrefix(i.Metadata, metadataVersionV1):
+		i.Metadata = strings.TrimPrefix(i.Metadata, metadataVersionV1)
+		return i.deserializeV1()
+	default:
+		return i.deserializeV1()
+	}
 }
 
-func (i offsetInfo) Deserialize() offsetInfo {
+func (i offsetInfo) deserializeV1() (offsetInfo, error) {
 	parts := strings.Split(i.Metadata, ",")
 	for _, k := range parts {
 		if k == "" {
@@ -71,7 +274,42 @@ func (i offsetInfo) Deserialize() offsetInfo {
 		i.PendingOffsets[offset] = struct{}{}
 	}
 
-	return i
+	return i, nil
+}
+
+func (i offsetInfo) deserializeV2() (offsetInfo, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(i.Metadata, metadataVersionV2))
+	// This should NEVER happen!
+	if err != nil {
+		return i, nil
+	}
+
+	prev := i.Offset
+	for len(raw) > 0 {
+		delta, n := binary.Varint(raw)
+		if n <= 0 {
+			return i, nil
+		}
+		raw = raw[n:]
+
+		run, n := binary.Varint(raw)
+		if n <= 0 || run <= 0 {
+			return i, nil
+		}
+		raw = raw[n:]
+
+		if run > maxPendingOffsetRun {
+			return i, ErrPendingOffsetRunTooLarge
+		}
+
+		base := prev + delta
+		for offset := base; offset < base+run; offset++ {
+			i.PendingOffsets[offset] = struct{}{}
+		}
+		prev = base + run - 1
+	}
+
+	return i, nil
 }
 
 func (i offsetInfo) NextOffset(fallback int64) int64 {
@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func sortTopicPartitions(tps []topicPartition) []topicPartition {
+	sort.Slice(tps, func(i, j int) bool {
+		if tps[i].Topic != tps[j].Topic {
+			return tps[i].Topic < tps[j].Topic
+		}
+		return tps[i].Partition < tps[j].Partition
+	})
+	return tps
+}
+
+func TestStickyUserDataRoundTrip(t *testing.T) {
+	owned := []topicPartition{
+		{Topic: "foo", Partition: 0},
+		{Topic: "foo", Partition: 1},
+		{Topic: "bar", Partition: 3},
+	}
+
+	encoded := encodeStickyUserData(owned)
+
+	decoded, err := decodeStickyUserData(encoded)
+	if err != nil {
+		t.Fatalf("decodeStickyUserData returned error: %v", err)
+	}
+
+	got := sortTopicPartitions(decoded.Owned)
+	want := sortTopicPartitions(owned)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round-trip mismatch: got %v, want %v", got, want)
+	}
+}
+
+func TestStickyUserDataEmpty(t *testing.T) {
+	decoded, err := decodeStickyUserData(nil)
+	if err != nil {
+		t.Fatalf("decodeStickyUserData(nil) returned error: %v", err)
+	}
+	if len(decoded.Owned) != 0 {
+		t.Fatalf("expected no owned partitions, got %v", decoded.Owned)
+	}
+}
+
+func TestStickyUserDataRejectsUnknownVersion(t *testing.T) {
+	if _, err := decodeStickyUserData([]byte{0xFF}); err == nil {
+		t.Fatal("expected an error decoding an unknown UserData version")
+	}
+}
+
+func newOffsetInfo(offset int64, pending ...int64) offsetInfo {
+	info := offsetInfo{Offset: offset, PendingOffsets: make(map[int64]struct{})}
+	for _, p := range pending {
+		info.PendingOffsets[p] = struct{}{}
+	}
+	return info
+}
+
+func assertPendingOffsetsEqual(t *testing.T, got map[int64]struct{}, want ...int64) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d pending offsets %v, want %d: %v", len(got), got, len(want), want)
+	}
+	for _, w := range want {
+		if _, ok := got[w]; !ok {
+			t.Fatalf("missing pending offset %d in %v", w, got)
+		}
+	}
+}
+
+func TestOffsetInfoSerializeDeserializeRoundTrip(t *testing.T) {
+	cases := []struct {
+		name    string
+		offset  int64
+		pending []int64
+	}{
+		{"empty", 10, nil},
+		{"singleton", 10, []int64{15}},
+		{"contiguous run", 10, []int64{11, 12, 13, 14}},
+		{"multiple runs", 10, []int64{11, 12, 15, 20, 21, 22}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := newOffsetInfo(tc.offset, tc.pending...)
+
+			serialized, err := original.Serialize(0)
+			if err != nil {
+				t.Fatalf("Serialize returned error: %v", err)
+			}
+
+			deserialized := offsetInfo{Offset: tc.offset, PendingOffsets: make(map[int64]struct{}), Metadata: serialized.Metadata}
+			deserialized, err = deserialized.Deserialize()
+			if err != nil {
+				t.Fatalf("Deserialize returned error: %v", err)
+			}
+
+			assertPendingOffsetsEqual(t, deserialized.PendingOffsets, tc.pending...)
+		})
+	}
+}
+
+func TestOffsetInfoSerializeRejectsOverflow(t *testing.T) {
+	pending := make([]int64, 0, 1000)
+	for i := int64(0); i < 1000; i += 2 {
+		pending = append(pending, i)
+	}
+	info := newOffsetInfo(0, pending...)
+
+	if _, err := info.Serialize(8); err != ErrMetadataTooLarge {
+		t.Fatalf("Serialize(8) returned %v, want ErrMetadataTooLarge", err)
+	}
+
+	if _, err := info.Serialize(0); err != nil {
+		t.Fatalf("Serialize(0) (no limit) returned unexpected error: %v", err)
+	}
+}
+
+func TestOffsetInfoDeserializeLegacyV1(t *testing.T) {
+	info := offsetInfo{Offset: 10, PendingOffsets: make(map[int64]struct{}), Metadata: "11,12,13,"}
+	info, err := info.Deserialize()
+	if err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+	assertPendingOffsetsEqual(t, info.PendingOffsets, 11, 12, 13)
+}
+
+func TestOffsetInfoDeserializeTaggedV1(t *testing.T) {
+	info := offsetInfo{Offset: 10, PendingOffsets: make(map[int64]struct{}), Metadata: metadataVersionV1 + "11,12,"}
+	info, err := info.Deserialize()
+	if err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+	assertPendingOffsetsEqual(t, info.PendingOffsets, 11, 12)
+}
+
+func TestOffsetInfoDeserializeRejectsHugeRun(t *testing.T) {
+	var buf []byte
+	buf = appendVarint(buf, 0)                     // delta
+	buf = appendVarint(buf, maxPendingOffsetRun+1) // run, one past the sanity cap
+	metadata := metadataVersionV2 + base64.RawStdEncoding.EncodeToString(buf)
+
+	info := offsetInfo{Offset: 10, PendingOffsets: make(map[int64]struct{}), Metadata: metadata}
+	if _, err := info.Deserialize(); err != ErrPendingOffsetRunTooLarge {
+		t.Fatalf("Deserialize returned %v, want ErrPendingOffsetRunTooLarge", err)
+	}
+}
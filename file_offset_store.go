@@ -0,0 +1,94 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileOffsetStore persists committed offsets as a JSON snapshot on disk.
+// It has no notion of group membership or fencing, so it is only suitable
+// for single-consumer recovery, not shared consumer groups.
+type FileOffsetStore struct {
+	path string
+
+	mutex sync.Mutex
+	data  map[string]offsetInfo
+	dirty bool
+}
+
+// NewFileOffsetStore loads the JSON snapshot at path, creating an empty
+// store if the file does not yet exist.
+func NewFileOffsetStore(path string) (*FileOffsetStore, error) {
+	s := &FileOffsetStore{path: path, data: make(map[string]offsetInfo)}
+
+	raw, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func fileOffsetStoreKey(group, topic string, partition int32) string {
+	return fmt.Sprintf("%s/%s/%d", group, topic, partition)
+}
+
+// Fetch implements OffsetStore.
+func (s *FileOffsetStore) Fetch(group, topic string, partition int32) (OffsetInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	info, ok := s.data[fileOffsetStoreKey(group, topic, partition)]
+	if !ok {
+		return OffsetInfo{Offset: -1, PendingOffsets: make(map[int64]struct{})}, nil
+	}
+	return info.Deserialize()
+}
+
+// Commit implements OffsetStore.
+func (s *FileOffsetStore) Commit(group, topic string, partition int32, info OffsetInfo) error {
+	serialized, err := info.Serialize(0)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.data[fileOffsetStoreKey(group, topic, partition)] = serialized
+	s.dirty = true
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// Flush implements OffsetStore. It writes the full snapshot to path if any
+// offset has changed since the last Flush.
+func (s *FileOffsetStore) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(s.path, raw, 0o644); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
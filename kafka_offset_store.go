@@ -0,0 +1,128 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+
+	"github.com/segmentio/sarama"
+)
+
+// KafkaOffsetStore is an OffsetStore that commits offsets through Kafka's
+// group coordinator via sarama.OffsetManager.
+type KafkaOffsetStore struct {
+	client sarama.Client
+
+	mutex    sync.Mutex
+	managers map[string]sarama.OffsetManager
+	poms     map[kafkaOffsetStoreKey]sarama.PartitionOffsetManager
+}
+
+type kafkaOffsetStoreKey struct {
+	group string
+	tp    topicPartition
+}
+
+// NewKafkaOffsetStore creates an OffsetStore that commits offsets through
+// Kafka's __consumer_offsets topic.
+func NewKafkaOffsetStore(client sarama.Client) *KafkaOffsetStore {
+	return &KafkaOffsetStore{
+		client:   client,
+		managers: make(map[string]sarama.OffsetManager),
+		poms:     make(map[kafkaOffsetStoreKey]sarama.PartitionOffsetManager),
+	}
+}
+
+func (s *KafkaOffsetStore) partitionManager(group, topic string, partition int32) (sarama.PartitionOffsetManager, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := kafkaOffsetStoreKey{group: group, tp: topicPartition{Topic: topic, Partition: partition}}
+	if pom, ok := s.poms[key]; ok {
+		return pom, nil
+	}
+
+	m, ok := s.managers[group]
+	if !ok {
+		var err error
+		m, err = sarama.NewOffsetManagerFromClient(group, s.client)
+		if err != nil {
+			return nil, err
+		}
+		s.managers[group] = m
+	}
+
+	pom, err := m.ManagePartition(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	s.poms[key] = pom
+	return pom, nil
+}
+
+// Fetch implements OffsetStore.
+func (s *KafkaOffsetStore) Fetch(group, topic string, partition int32) (OffsetInfo, error) {
+	pom, err := s.partitionManager(group, topic, partition)
+	if err != nil {
+		return OffsetInfo{}, err
+	}
+
+	offset, metadata := pom.NextOffset()
+	info := offsetInfo{Offset: offset, Metadata: metadata, PendingOffsets: make(map[int64]struct{})}
+	return info.Deserialize()
+}
+
+// Commit implements OffsetStore.
+func (s *KafkaOffsetStore) Commit(group, topic string, partition int32, info OffsetInfo) error {
+	pom, err := s.partitionManager(group, topic, partition)
+	if err != nil {
+		return err
+	}
+
+	serialized, err := info.Serialize(defaultMaxMetadataBytes)
+	if err != nil {
+		return err
+	}
+
+	pom.MarkOffset(serialized.Offset, serialized.Metadata)
+	return nil
+}
+
+// Flush implements OffsetStore. It closes every PartitionOffsetManager and
+// OffsetManager, which blocks until sarama has flushed their pending
+// commits, or returns ctx.Err() if ctx is cancelled first.
+func (s *KafkaOffsetStore) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for tp, pom := range s.poms {
+		if err := closeWithContext(ctx, pom.Close); err != nil {
+			return err
+		}
+		delete(s.poms, tp)
+	}
+
+	for group, m := range s.managers {
+		if err := closeWithContext(ctx, m.Close); err != nil {
+			return err
+		}
+		delete(s.managers, group)
+	}
+
+	return nil
+}
+
+// closeWithContext runs close in its own goroutine and returns ctx.Err()
+// if ctx is cancelled before close returns, so a blocking sarama Close
+// call can't make Flush ignore its ctx argument. close keeps running in
+// the background after a cancellation; its result is simply discarded.
+func closeWithContext(ctx context.Context, close func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- close() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
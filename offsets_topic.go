@@ -0,0 +1,350 @@
+package cluster
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/sarama"
+)
+
+const offsetsTopic = "__consumer_offsets"
+
+// OffsetMessage is a decoded OffsetCommit record read from the
+// __consumer_offsets topic.
+type OffsetMessage struct {
+	Group     string
+	Topic     string
+	Partition int32
+	Offset    int64
+	Metadata  string
+	Timestamp time.Time
+}
+
+// GroupMetadataMessage is a decoded GroupMetadata record read from the
+// __consumer_offsets topic.
+type GroupMetadataMessage struct {
+	Group     string
+	Timestamp time.Time
+}
+
+// OffsetsTopicConsumer tails every partition of __consumer_offsets and
+// emits decoded OffsetMessage and GroupMetadataMessage values, letting
+// operators build lag dashboards or audit pending-offset metadata without
+// polling every group's OffsetManager.
+type OffsetsTopicConsumer struct {
+	client   sarama.Client
+	consumer sarama.Consumer
+
+	watch         bool
+	watchInterval time.Duration
+
+	messages chan OffsetMessage
+	groups   chan GroupMetadataMessage
+	errors   chan error
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+
+	mutex  sync.Mutex
+	active map[int32]context.CancelFunc
+}
+
+// NewOffsetsTopicConsumer creates an OffsetsTopicConsumer against client.
+// When watch is true, Run refreshes the __consumer_offsets partition list
+// every watchInterval so partitions added by a broker scale-out are picked
+// up automatically.
+func NewOffsetsTopicConsumer(client sarama.Client, watch bool, watchInterval time.Duration) (*OffsetsTopicConsumer, error) {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OffsetsTopicConsumer{
+		client:        client,
+		consumer:      consumer,
+		watch:         watch,
+		watchInterval: watchInterval,
+		messages:      make(chan OffsetMessage, 256),
+		groups:        make(chan GroupMetadataMessage, 256),
+		errors:        make(chan error),
+		active:        make(map[int32]context.CancelFunc),
+	}, nil
+}
+
+// Messages returns the channel of decoded offset-commit records.
+func (c *OffsetsTopicConsumer) Messages() <-chan OffsetMessage { return c.messages }
+
+// GroupMetadata returns the channel of decoded group-metadata records.
+func (c *OffsetsTopicConsumer) GroupMetadata() <-chan GroupMetadataMessage { return c.groups }
+
+// Errors returns the channel of errors encountered while consuming or
+// decoding __consumer_offsets.
+func (c *OffsetsTopicConsumer) Errors() <-chan error { return c.errors }
+
+// Run attaches a PartitionConsumer to every partition of __consumer_offsets
+// and starts decoding records. It returns once the initial attach has
+// completed; consumption continues in the background until ctx is
+// cancelled or Close is called. Run derives its own cancellable context
+// from ctx so that Close can stop the background watch-refresh loop even
+// when ctx itself is never cancelled (e.g. context.Background()).
+func (c *OffsetsTopicConsumer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+
+	if err := c.sync(ctx); err != nil {
+		cancel()
+		return err
+	}
+
+	if !c.watch {
+		return nil
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.watchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.sync(ctx); err != nil {
+					select {
+					case c.errors <- err:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops every per-partition goroutine and the watch-refresh loop
+// started by Run, then releases the underlying consumer.
+func (c *OffsetsTopicConsumer) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.mutex.Lock()
+	for partition, cancel := range c.active {
+		cancel()
+		delete(c.active, partition)
+	}
+	c.mutex.Unlock()
+
+	c.wg.Wait()
+	return c.consumer.Close()
+}
+
+// sync attaches a PartitionConsumer to any __consumer_offsets partition not
+// already being consumed.
+func (c *OffsetsTopicConsumer) sync(ctx context.Context) error {
+	partitions, err := c.client.Partitions(offsetsTopic)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, partition := range partitions {
+		if _, ok := c.active[partition]; ok {
+			continue
+		}
+
+		pcm, err := c.consumer.ConsumePartition(offsetsTopic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return err
+		}
+
+		pctx, cancel := context.WithCancel(ctx)
+		c.active[partition] = cancel
+
+		c.wg.Add(1)
+		go c.consumePartition(pctx, pcm)
+	}
+
+	return nil
+}
+
+func (c *OffsetsTopicConsumer) consumePartition(ctx context.Context, pcm sarama.PartitionConsumer) {
+	defer c.wg.Done()
+	defer pcm.AsyncClose()
+
+	for {
+		select {
+		case msg, ok := <-pcm.Messages():
+			if !ok {
+				return
+			}
+			c.decode(msg)
+		case err, ok := <-pcm.Errors():
+			if !ok {
+				return
+			}
+			select {
+			case c.errors <- err:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// decode parses the key/value schema of a __consumer_offsets record and
+// emits the corresponding OffsetMessage or GroupMetadataMessage. Key
+// version 0 or 1 is an offset commit; version 2 is group metadata. Unknown
+// versions are silently skipped, matching how real consumers ignore
+// schema additions they don't understand.
+func (c *OffsetsTopicConsumer) decode(msg *sarama.ConsumerMessage) {
+	if len(msg.Key) < 2 {
+		return
+	}
+
+	switch version := int16(binary.BigEndian.Uint16(msg.Key)); version {
+	case 0, 1:
+		om, err := decodeOffsetCommitKey(msg.Key)
+		if err != nil {
+			c.emitError("offsets-topic/key", err)
+			return
+		}
+
+		if len(msg.Value) == 0 {
+			// Tombstone: the group stopped committing offsets for this
+			// topic-partition; the log compactor will drop the record.
+			return
+		}
+
+		value, err := decodeOffsetCommitValue(msg.Value)
+		if err != nil {
+			c.emitError("offsets-topic/value", err)
+			return
+		}
+
+		om.Offset = value.offset
+		om.Metadata = value.metadata
+		om.Timestamp = msg.Timestamp
+
+		select {
+		case c.messages <- om:
+		default:
+		}
+	case 2:
+		group, err := decodeGroupMetadataKey(msg.Key)
+		if err != nil {
+			c.emitError("offsets-topic/group-key", err)
+			return
+		}
+
+		select {
+		case c.groups <- GroupMetadataMessage{Group: group, Timestamp: msg.Timestamp}:
+		default:
+		}
+	}
+}
+
+func (c *OffsetsTopicConsumer) emitError(ctx string, err error) {
+	select {
+	case c.errors <- &Error{Ctx: ctx, error: err}:
+	default:
+	}
+}
+
+// --------------------------------------------------------------------
+
+type offsetCommitValue struct {
+	offset   int64
+	metadata string
+}
+
+func decodeOffsetCommitKey(key []byte) (OffsetMessage, error) {
+	buf := key[2:]
+
+	group, n, err := readKafkaString(buf)
+	if err != nil {
+		return OffsetMessage{}, err
+	}
+	buf = buf[n:]
+
+	topic, n, err := readKafkaString(buf)
+	if err != nil {
+		return OffsetMessage{}, err
+	}
+	buf = buf[n:]
+
+	if len(buf) < 4 {
+		return OffsetMessage{}, fmt.Errorf("cluster: truncated offset commit key")
+	}
+
+	return OffsetMessage{
+		Group:     group,
+		Topic:     topic,
+		Partition: int32(binary.BigEndian.Uint32(buf)),
+	}, nil
+}
+
+func decodeOffsetCommitValue(value []byte) (offsetCommitValue, error) {
+	if len(value) < 2+8 {
+		return offsetCommitValue{}, fmt.Errorf("cluster: truncated offset commit value")
+	}
+
+	version := int16(binary.BigEndian.Uint16(value))
+
+	// Every OffsetCommitValue version (0-3) leads with the committed
+	// offset; version 3 (KIP-320, brokers >= 2.1) then inserts a 4-byte
+	// leader_epoch before metadata, which the older versions don't have.
+	buf := value[2:]
+	offset := int64(binary.BigEndian.Uint64(buf))
+	buf = buf[8:]
+
+	if version >= 3 {
+		if len(buf) < 4 {
+			return offsetCommitValue{}, fmt.Errorf("cluster: truncated offset commit value leader epoch")
+		}
+		buf = buf[4:]
+	}
+
+	metadata, _, err := readKafkaString(buf)
+	if err != nil {
+		return offsetCommitValue{}, err
+	}
+
+	return offsetCommitValue{offset: offset, metadata: metadata}, nil
+}
+
+func decodeGroupMetadataKey(key []byte) (string, error) {
+	group, _, err := readKafkaString(key[2:])
+	return group, err
+}
+
+// readKafkaString reads a Kafka protocol string: an int16 byte length
+// followed by the UTF-8 bytes. It returns the decoded string and the
+// number of bytes consumed from buf.
+func readKafkaString(buf []byte) (string, int, error) {
+	if len(buf) < 2 {
+		return "", 0, fmt.Errorf("cluster: truncated string length")
+	}
+
+	length := int(int16(binary.BigEndian.Uint16(buf)))
+	if length < 0 {
+		return "", 2, nil
+	}
+	if len(buf) < 2+length {
+		return "", 0, fmt.Errorf("cluster: truncated string")
+	}
+
+	return string(buf[2 : 2+length]), 2 + length, nil
+}
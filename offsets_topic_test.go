@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func encodeKafkaTestString(s string) []byte {
+	buf := make([]byte, 2+len(s))
+	binary.BigEndian.PutUint16(buf, uint16(len(s)))
+	copy(buf[2:], s)
+	return buf
+}
+
+func buildOffsetCommitValue(version int16, offset int64, leaderEpoch *int32, metadata string) []byte {
+	buf := make([]byte, 2, 2+8+4+2+len(metadata))
+	binary.BigEndian.PutUint16(buf, uint16(version))
+
+	offBuf := make([]byte, 8)
+	binary.BigEndian.PutUint64(offBuf, uint64(offset))
+	buf = append(buf, offBuf...)
+
+	if leaderEpoch != nil {
+		epochBuf := make([]byte, 4)
+		binary.BigEndian.PutUint32(epochBuf, uint32(*leaderEpoch))
+		buf = append(buf, epochBuf...)
+	}
+
+	return append(buf, encodeKafkaTestString(metadata)...)
+}
+
+func TestDecodeOffsetCommitValue(t *testing.T) {
+	epoch := int32(7)
+
+	cases := []struct {
+		name        string
+		version     int16
+		leaderEpoch *int32
+		offset      int64
+		metadata    string
+	}{
+		{"v0", 0, nil, 42, "meta-v0"},
+		{"v1", 1, nil, 43, "meta-v1"},
+		{"v2", 2, nil, 44, "meta-v2"},
+		{"v3 with leader epoch", 3, &epoch, 45, "meta-v3"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			value := buildOffsetCommitValue(tc.version, tc.offset, tc.leaderEpoch, tc.metadata)
+
+			got, err := decodeOffsetCommitValue(value)
+			if err != nil {
+				t.Fatalf("decodeOffsetCommitValue returned error: %v", err)
+			}
+			if got.offset != tc.offset {
+				t.Fatalf("offset = %d, want %d", got.offset, tc.offset)
+			}
+			if got.metadata != tc.metadata {
+				t.Fatalf("metadata = %q, want %q", got.metadata, tc.metadata)
+			}
+		})
+	}
+}
+
+func TestDecodeOffsetCommitValueTruncated(t *testing.T) {
+	cases := []struct {
+		name  string
+		value []byte
+	}{
+		{"empty", nil},
+		{"shorter than version+offset", []byte{0, 0, 0}},
+		{"v3 missing leader epoch", buildOffsetCommitValue(3, 1, nil, "")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := decodeOffsetCommitValue(tc.value); err == nil {
+				t.Fatalf("expected an error decoding truncated value %v", tc.value)
+			}
+		})
+	}
+}
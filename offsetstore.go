@@ -0,0 +1,23 @@
+package cluster
+
+import "context"
+
+// OffsetStore abstracts how a partitionConsumer fetches and persists
+// committed offsets, decoupling offset tracking from Kafka's
+// __consumer_offsets topic so a Consumer can commit offsets transactionally
+// alongside external state (e.g. the "exactly-once sink" pattern of
+// committing to a business database).
+type OffsetStore interface {
+	// Fetch returns the last committed OffsetInfo for group/topic/partition.
+	// Implementations return OffsetInfo{Offset: -1} when nothing has been
+	// committed yet.
+	Fetch(group, topic string, partition int32) (OffsetInfo, error)
+
+	// Commit persists info as the latest committed offset for
+	// group/topic/partition.
+	Commit(group, topic string, partition int32, info OffsetInfo) error
+
+	// Flush blocks until all outstanding commits are durable, or ctx is
+	// cancelled.
+	Flush(ctx context.Context) error
+}
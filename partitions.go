@@ -13,11 +13,21 @@ type partitionConsumer struct {
 	state partitionState
 	mutex sync.Mutex
 
+	group, topic     string
+	partition        int32
+	store            OffsetStore
+	maxMetadataBytes int
+
 	closed      bool
 	dying, dead chan none
 }
 
-func newPartitionConsumer(manager sarama.Consumer, topic string, partition int32, info offsetInfo, defaultOffset int64) (*partitionConsumer, error) {
+// newPartitionConsumer starts consuming topic/partition from the offset
+// recorded in info. When store is non-nil, CommitOffset persists offsets
+// through it instead of Kafka's __consumer_offsets topic. maxMetadataBytes
+// caps the size of the serialized PendingOffsets metadata; 0 falls back to
+// defaultMaxMetadataBytes.
+func newPartitionConsumer(manager sarama.Consumer, topic string, partition int32, info offsetInfo, defaultOffset int64, group string, store OffsetStore, maxMetadataBytes int) (*partitionConsumer, error) {
 	pcm, err := manager.ConsumePartition(topic, partition, info.NextOffset(defaultOffset))
 
 	// Resume from default offset, if requested offset is out-of-range
@@ -29,10 +39,20 @@ func newPartitionConsumer(manager sarama.Consumer, topic string, partition int32
 		return nil, err
 	}
 
+	if maxMetadataBytes <= 0 {
+		maxMetadataBytes = defaultMaxMetadataBytes
+	}
+
 	return &partitionConsumer{
 		pcm:   pcm,
 		state: partitionState{Info: info},
 
+		group:            group,
+		topic:            topic,
+		partition:        partition,
+		store:            store,
+		maxMetadataBytes: maxMetadataBytes,
+
 		dying: make(chan none),
 		dead:  make(chan none),
 	}, nil
@@ -88,9 +108,18 @@ func (c *partitionConsumer) State() partitionState {
 	c.mutex.Lock()
 	state := c.state
 	if state.Info.Metadata == "" {
-		state.Info = state.Info.Serialize()
+		serialized, err := state.Info.Serialize(c.maxMetadataBytes)
+		if err != nil {
+			state.Err = err
+		} else {
+			state.Info = serialized
+		}
 	} else if len(state.Info.PendingOffsets) == 0 {
-		state.Info = state.Info.Deserialize()
+		if deserialized, err := state.Info.Deserialize(); err != nil {
+			state.Err = err
+		} else {
+			state.Info = deserialized
+		}
 	}
 
 	c.mutex.Unlock()
@@ -114,6 +143,31 @@ func (c *partitionConsumer) AddPendingOffset(offset int64) {
 	c.state.Info.PendingOffsets[offset] = struct{}{}
 }
 
+// CommitOffset persists the partition's dirty offset through the
+// configured OffsetStore, if any, and clears the dirty flag on success. It
+// is a no-op when no store is configured, e.g. when the Consumer relies on
+// Kafka's own OffsetManager instead.
+func (c *partitionConsumer) CommitOffset() error {
+	if c == nil || c.store == nil {
+		return nil
+	}
+
+	state := c.State()
+	if !state.Dirty {
+		return nil
+	}
+	if state.Err != nil {
+		return state.Err
+	}
+
+	if err := c.store.Commit(c.group, c.topic, c.partition, state.Info); err != nil {
+		return err
+	}
+
+	c.MarkCommitted(state.Info.Offset)
+	return nil
+}
+
 func (c *partitionConsumer) SetOffset(offset int64) {
 	c.pcm.SetOffset(offset)
 }
@@ -144,6 +198,13 @@ func (c *partitionConsumer) MarkOffset(offset int64, metadata string) {
 type partitionState struct {
 	Info  offsetInfo
 	Dirty bool
+
+	// Err is set by State when Info.Serialize fails, e.g. because the
+	// pending offsets no longer fit under maxMetadataBytes, or when
+	// Info.Deserialize rejects the stored Metadata. The owning
+	// Consumer is responsible for surfacing it through its Errors()
+	// channel instead of attempting the corrupt commit.
+	Err error
 }
 
 // --------------------------------------------------------------------
@@ -210,6 +271,44 @@ func (m *partitionMap) Stop() {
 	wg.Wait()
 }
 
+// Retain closes and removes every partition not listed in keep, leaving the
+// retained entries--and their PendingOffsets state--untouched. It is the
+// keep-set form of Revoke: under rebalanceProtocolCooperative, keep is the
+// intersection of the current ownership with the new assignment.
+func (m *partitionMap) Retain(keep []topicPartition) {
+	keepSet := make(map[topicPartition]none, len(keep))
+	for _, tp := range keep {
+		keepSet[tp] = none{}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for tp, pc := range m.data {
+		if _, ok := keepSet[tp]; !ok {
+			_ = pc.Close()
+			delete(m.data, tp)
+		}
+	}
+}
+
+// Revoke closes and removes only the partitions listed in drop, leaving
+// every other entry--and its PendingOffsets state--untouched across
+// generations. It is the complement of Retain: callers that already know
+// which partitions were lost use Revoke directly instead of recomputing
+// the keep set.
+func (m *partitionMap) Revoke(drop []topicPartition) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, tp := range drop {
+		if pc, ok := m.data[tp]; ok {
+			_ = pc.Close()
+			delete(m.data, tp)
+		}
+	}
+}
+
 func (m *partitionMap) Clear() {
 	m.mutex.Lock()
 	for tp := range m.data {
@@ -218,6 +317,87 @@ func (m *partitionMap) Clear() {
 	m.mutex.Unlock()
 }
 
+// Owned returns the partitions currently running.
+func (m *partitionMap) Owned() []topicPartition {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	owned := make([]topicPartition, 0, len(m.data))
+	for tp := range m.data {
+		owned = append(owned, tp)
+	}
+	return owned
+}
+
+// planCooperativeRebalance computes, for a member moving from owned to
+// assigned, which partitions to keep running untouched, which to revoke,
+// and which are newly assigned and still need to be started. Under
+// incremental cooperative rebalancing this runs once per generation: the
+// first SyncGroup of a rebalance hands a member assigned == owned minus
+// the partitions it's giving up (so add is empty and only revoke fires);
+// after the member re-JoinGroups with its trimmed ownership, a follow-up
+// generation's SyncGroup hands it assigned == that trimmed ownership plus
+// whatever it was newly given (so keep already matches owned and only add
+// fires). Calling it with the same inputs always produces the same plan,
+// regardless of which generation it's in.
+func planCooperativeRebalance(owned, assigned []topicPartition) (keep, add, revoke []topicPartition) {
+	assignedSet := make(map[topicPartition]none, len(assigned))
+	for _, tp := range assigned {
+		assignedSet[tp] = none{}
+	}
+
+	ownedSet := make(map[topicPartition]none, len(owned))
+	for _, tp := range owned {
+		ownedSet[tp] = none{}
+	}
+
+	for _, tp := range owned {
+		if _, ok := assignedSet[tp]; ok {
+			keep = append(keep, tp)
+		} else {
+			revoke = append(revoke, tp)
+		}
+	}
+
+	for _, tp := range assigned {
+		if _, ok := ownedSet[tp]; !ok {
+			add = append(add, tp)
+		}
+	}
+
+	return
+}
+
+// Transition moves m from its current set of running partitionConsumers to
+// assigned, following the rebalanceProtocol strategy requires.
+//
+// Eager strategies (StrategyRange, StrategyRoundRobin) stop and clear
+// every partition, as they always have: the caller starts assigned from
+// scratch. strategyCooperativeSticky instead calls Revoke with only the
+// partitions owned but not in assigned, so the intersection keeps running
+// uninterrupted across the rebalance; add holds the partitions the caller
+// still needs to start.
+//
+// This is the partition-transition half of incremental cooperative
+// rebalancing (KIP-429), built ahead of a caller: no Consumer in this
+// package negotiates the COOPERATIVE protocol over JoinGroup/SyncGroup or
+// runs a leader-side sticky assignor yet, which is why strategy can only
+// be strategyCooperativeSticky internally for now--see its doc comment in
+// cluster.go. The sticky UserData codec (encodeStickyUserData) that a real
+// assignor would use lives alongside it, also unexported until something
+// calls it.
+func (m *partitionMap) Transition(strategy Strategy, assigned []topicPartition) (add []topicPartition) {
+	if protocolFor(strategy) != rebalanceProtocolCooperative {
+		m.Stop()
+		m.Clear()
+		return assigned
+	}
+
+	_, add, revoke := planCooperativeRebalance(m.Owned(), assigned)
+	m.Revoke(revoke)
+	return add
+}
+
 func (m *partitionMap) Info() map[string][]int32 {
 	info := make(map[string][]int32)
 	m.mutex.RLock()
@@ -0,0 +1,55 @@
+package cluster
+
+import "testing"
+
+func tpSet(tps []topicPartition) map[topicPartition]bool {
+	set := make(map[topicPartition]bool, len(tps))
+	for _, tp := range tps {
+		set[tp] = true
+	}
+	return set
+}
+
+func TestPlanCooperativeRebalance(t *testing.T) {
+	p0 := topicPartition{Topic: "foo", Partition: 0}
+	p1 := topicPartition{Topic: "foo", Partition: 1}
+	p2 := topicPartition{Topic: "foo", Partition: 2}
+
+	owned := []topicPartition{p0, p1}
+	assigned := []topicPartition{p0, p2}
+
+	keep, add, revoke := planCooperativeRebalance(owned, assigned)
+
+	if got := tpSet(keep); len(got) != 1 || !got[p0] {
+		t.Fatalf("keep = %v, want [%v]", keep, p0)
+	}
+	if got := tpSet(add); len(got) != 1 || !got[p2] {
+		t.Fatalf("add = %v, want [%v]", add, p2)
+	}
+	if got := tpSet(revoke); len(got) != 1 || !got[p1] {
+		t.Fatalf("revoke = %v, want [%v]", revoke, p1)
+	}
+}
+
+func TestPlanCooperativeRebalanceNoChange(t *testing.T) {
+	owned := []topicPartition{{Topic: "foo", Partition: 0}}
+
+	keep, add, revoke := planCooperativeRebalance(owned, owned)
+
+	if len(keep) != 1 || len(add) != 0 || len(revoke) != 0 {
+		t.Fatalf("got keep=%v add=%v revoke=%v, want keep=owned, no add/revoke", keep, add, revoke)
+	}
+}
+
+func TestPlanCooperativeRebalanceFreshMember(t *testing.T) {
+	assigned := []topicPartition{{Topic: "foo", Partition: 0}, {Topic: "foo", Partition: 1}}
+
+	keep, add, revoke := planCooperativeRebalance(nil, assigned)
+
+	if len(keep) != 0 || len(revoke) != 0 {
+		t.Fatalf("got keep=%v revoke=%v, want both empty for a member with no prior ownership", keep, revoke)
+	}
+	if got := tpSet(add); len(got) != 2 || !got[assigned[0]] || !got[assigned[1]] {
+		t.Fatalf("add = %v, want %v", add, assigned)
+	}
+}
@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqlExecutor is satisfied by both *sql.DB and *sql.Tx, letting commit run
+// against either a standalone connection or a caller-supplied transaction.
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SQLOffsetStore commits offsets to a SQL table. Callers that need the
+// "exactly-once sink" pattern -- the business write and the offset commit
+// succeeding or failing together -- use CommitTx to join their own
+// transaction instead of Commit.
+//
+// Upserts are done as a portable UPDATE-then-INSERT-if-missing rather than
+// a dialect-specific "ON DUPLICATE KEY" / "ON CONFLICT" clause, so the same
+// store works against MySQL, Postgres, and SQLite. This assumes commits for
+// a given group/topic/partition are serialized by the caller, which holds
+// here since each partitionConsumer owns its own row.
+type SQLOffsetStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLOffsetStore creates an OffsetStore backed by table, which must have
+// columns (grp, topic, partition, offset, metadata) uniquely keyed on
+// (grp, topic, partition).
+func NewSQLOffsetStore(db *sql.DB, table string) *SQLOffsetStore {
+	return &SQLOffsetStore{db: db, table: table}
+}
+
+// Fetch implements OffsetStore.
+func (s *SQLOffsetStore) Fetch(group, topic string, partition int32) (OffsetInfo, error) {
+	return s.fetch(s.db, group, topic, partition)
+}
+
+// Commit implements OffsetStore. It commits against a standalone
+// connection; use CommitTx to join an in-flight business-write
+// transaction.
+func (s *SQLOffsetStore) Commit(group, topic string, partition int32, info OffsetInfo) error {
+	return s.commit(s.db, group, topic, partition, info)
+}
+
+// CommitTx persists info through tx instead of a standalone write, so the
+// offset commit joins the caller's in-flight business-write transaction
+// and both succeed or fail together. The caller owns tx's lifecycle
+// (commit/rollback); CommitTx never commits or rolls back tx itself.
+func (s *SQLOffsetStore) CommitTx(tx *sql.Tx, group, topic string, partition int32, info OffsetInfo) error {
+	return s.commit(tx, group, topic, partition, info)
+}
+
+// Flush implements OffsetStore. Commits already land transactionally, so
+// there is nothing left to flush.
+func (s *SQLOffsetStore) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *SQLOffsetStore) fetch(exec sqlExecutor, group, topic string, partition int32) (OffsetInfo, error) {
+	query := fmt.Sprintf("SELECT offset, metadata FROM %s WHERE grp = ? AND topic = ? AND partition = ?", s.table)
+	row := exec.QueryRow(query, group, topic, partition)
+
+	var offset int64
+	var metadata string
+	switch err := row.Scan(&offset, &metadata); err {
+	case sql.ErrNoRows:
+		return OffsetInfo{Offset: -1, PendingOffsets: make(map[int64]struct{})}, nil
+	case nil:
+		info := offsetInfo{Offset: offset, Metadata: metadata, PendingOffsets: make(map[int64]struct{})}
+		return info.Deserialize()
+	default:
+		return OffsetInfo{}, err
+	}
+}
+
+func (s *SQLOffsetStore) commit(exec sqlExecutor, group, topic string, partition int32, info OffsetInfo) error {
+	serialized, err := info.Serialize(0)
+	if err != nil {
+		return err
+	}
+
+	update := fmt.Sprintf("UPDATE %s SET offset = ?, metadata = ? WHERE grp = ? AND topic = ? AND partition = ?", s.table)
+	res, err := exec.Exec(update, serialized.Offset, serialized.Metadata, group, topic, partition)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (grp, topic, partition, offset, metadata) VALUES (?, ?, ?, ?, ?)", s.table)
+	_, err = exec.Exec(insert, group, topic, partition, serialized.Offset, serialized.Metadata)
+	return err
+}